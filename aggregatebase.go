@@ -27,7 +27,7 @@ package eventhorizon
 // aggregate base is recommended:
 //   func NewUserAggregate(id eh.UUID) *InvitationAggregate {
 //       return &UserAggregate{
-//           AggregateBase: eh.NewAggregateBase(UserAggregateType, id),
+//           AggregateBase: eh.NewAggregateBase(UserAggregateType, id, ""),
 //       }
 //   }
 //
@@ -54,15 +54,19 @@ package eventhorizon
 type AggregateBase struct {
 	aggregateType     AggregateType
 	id                UUID
+	tenant            string
 	version           int
+	nextVersion       int
 	uncommittedEvents []Event
 }
 
-// NewAggregateBase creates an aggregate.
-func NewAggregateBase(aggregateType AggregateType, id UUID) *AggregateBase {
+// NewAggregateBase creates an aggregate for the given tenant. tenant may be
+// left empty for single-tenant deployments.
+func NewAggregateBase(aggregateType AggregateType, id UUID, tenant string) *AggregateBase {
 	return &AggregateBase{
 		aggregateType:     aggregateType,
 		id:                id,
+		tenant:            tenant,
 		uncommittedEvents: []Event{},
 	}
 }
@@ -77,11 +81,32 @@ func (a *AggregateBase) AggregateID() UUID {
 	return a.id
 }
 
+// Tenant returns the tenant this aggregate belongs to, or "" for
+// single-tenant deployments.
+func (a *AggregateBase) Tenant() string {
+	return a.tenant
+}
+
+// SetTenant implements the TenantSetter interface, letting a repository
+// assign a tenant to an aggregate produced by a factory before replaying
+// its events, since aggregate factories only take a UUID.
+func (a *AggregateBase) SetTenant(tenant string) {
+	a.tenant = tenant
+}
+
 // Version implements the Version method of the Aggregate interface.
 func (a *AggregateBase) Version() int {
 	return a.version
 }
 
+// SetVersion implements the VersionSetter interface, letting a repository
+// seed an aggregate's version from a snapshot before replaying the events
+// newer than it, instead of starting from zero.
+func (a *AggregateBase) SetVersion(version int) {
+	a.version = version
+	a.nextVersion = version
+}
+
 // IncrementVersion increments the version of the aggregate and should be called
 // after an event has been applied successfully in ApplyEvent.
 func (a *AggregateBase) IncrementVersion() {
@@ -89,16 +114,23 @@ func (a *AggregateBase) IncrementVersion() {
 }
 
 // NewEvent implements the NewEvent method of the Aggregate interface.
-// The created event is only valid for the current version of the aggregate.
-// If there are uncommitted events it will mean that all the uncommitted events
-// could possibly have the same versions as they haven't been applied yet!
-// The result is that the aggregate base only supports one uncommitted event in.
+// Each call stamps the event with the next version after the committed
+// version and any events already created by NewEvent since the last
+// commit, whether or not they were stored, so that several
+// events can be stored on the same aggregate before it is saved without
+// colliding on the same version.
 func (a *AggregateBase) NewEvent(eventType EventType, data EventData) Event {
+	if a.nextVersion < a.version {
+		a.nextVersion = a.version
+	}
+	a.nextVersion++
+
 	e := NewEvent(eventType, data)
 	if e, ok := e.(event); ok {
 		e.aggregateType = a.aggregateType
 		e.aggregateID = a.id
-		e.version = a.Version() + 1
+		e.tenant = a.tenant
+		e.version = a.nextVersion
 		return e
 	}
 	return e
@@ -114,7 +146,13 @@ func (a *AggregateBase) UncommittedEvents() []Event {
 	return a.uncommittedEvents
 }
 
-// ClearUncommittedEvents implements the ClearUncommittedEvents method of the Aggregate interface.
+// ClearUncommittedEvents implements the ClearUncommittedEvents method of the
+// Aggregate interface. It is called once the uncommitted events have been
+// successfully saved, and advances the committed version by the number of
+// events that were just committed rather than resetting it, so that
+// NewEvent keeps stamping events after the true committed head.
 func (a *AggregateBase) ClearUncommittedEvents() {
+	a.version += len(a.uncommittedEvents)
+	a.nextVersion = a.version
 	a.uncommittedEvents = []Event{}
 }
@@ -0,0 +1,136 @@
+// Copyright (c) 2019 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudevents provides a bidirectional adapter between
+// eventhorizon.Event and the CNCF CloudEvents v1.0 spec, so event buses can
+// exchange events with non-eventhorizon producers and consumers.
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// EventVersionExtension is the CloudEvents extension attribute used to
+// carry the eventhorizon event version, since CloudEvents has no native
+// equivalent.
+const EventVersionExtension = "eheventversion"
+
+// TenantExtension is the CloudEvents extension attribute used to carry the
+// eventhorizon tenant. It is omitted for single-tenant events.
+const TenantExtension = "ehtenant"
+
+// DataContentType is the content type used for the CloudEvent data payload.
+const DataContentType = "application/json"
+
+// ToCloudEvent converts an eventhorizon Event to a CloudEvents v1.0 event.
+// The event's Data is marshaled to JSON as the CloudEvent payload.
+func ToCloudEvent(e eh.Event) (cloudevents.Event, error) {
+	ce := cloudevents.NewEvent()
+	ce.SetSpecVersion(cloudevents.VersionV1)
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return cloudevents.Event{}, fmt.Errorf("cloudevents: could not generate id: %w", err)
+	}
+	ce.SetID(id.String())
+
+	ce.SetType(string(e.EventType()))
+	ce.SetSource(string(e.AggregateType()))
+	ce.SetSubject(string(e.AggregateID()))
+	ce.SetTime(e.Timestamp())
+	ce.SetExtension(EventVersionExtension, e.Version())
+	if tenant, ok := e.(interface{ Tenant() string }); ok && tenant.Tenant() != "" {
+		ce.SetExtension(TenantExtension, tenant.Tenant())
+	}
+
+	if err := ce.SetData(DataContentType, e.Data()); err != nil {
+		return cloudevents.Event{}, fmt.Errorf("cloudevents: could not set data: %w", err)
+	}
+
+	return ce, nil
+}
+
+// FromCloudEvent converts a CloudEvents v1.0 event back into an
+// eventhorizon Event. The EventType is taken from the CloudEvent type, and
+// its data is unmarshaled into the struct registered for that type with
+// RegisterEventData.
+func FromCloudEvent(ce cloudevents.Event) (eh.Event, error) {
+	eventType := eh.EventType(ce.Type())
+
+	data, err := eh.CreateEventData(eventType)
+	if err != nil {
+		return nil, fmt.Errorf("cloudevents: could not create event data: %w", err)
+	}
+
+	if err := json.Unmarshal(ce.Data(), data); err != nil {
+		return nil, fmt.Errorf("cloudevents: could not unmarshal event data: %w", err)
+	}
+
+	event := eh.NewEventForAggregate(
+		eventType,
+		data,
+		ce.Time(),
+		eh.AggregateType(ce.Source()),
+		eh.UUID(ce.Subject()),
+		eventVersion(ce),
+		tenant(ce),
+	)
+
+	return event, nil
+}
+
+// tenant extracts the ehtenant extension, returning "" if it is missing
+// (e.g. events produced by non-eventhorizon CloudEvents sources, which are
+// treated as belonging to the default single tenant).
+func tenant(ce cloudevents.Event) string {
+	ext, ok := ce.Extensions()[TenantExtension]
+	if !ok {
+		return ""
+	}
+	if s, ok := ext.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// eventVersion extracts the eheventversion extension, defaulting to 0 if it
+// is missing or not numeric (e.g. events produced by non-eventhorizon
+// CloudEvents sources).
+func eventVersion(ce cloudevents.Event) int {
+	ext, ok := ce.Extensions()[EventVersionExtension]
+	if !ok {
+		return 0
+	}
+	switch v := ext.(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2019 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudevents
+
+import (
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+const testEventType eh.EventType = "CloudEventsTestEvent"
+const testAggregateType eh.AggregateType = "CloudEventsTestAggregate"
+
+type testEventData struct {
+	Content string
+}
+
+func init() {
+	eh.RegisterEventData(testEventType, func() eh.EventData {
+		return &testEventData{}
+	})
+}
+
+func TestToAndFromCloudEvent(t *testing.T) {
+	event := eh.NewEventForAggregate(testEventType, &testEventData{Content: "event1"},
+		time.Now().UTC(), testAggregateType, eh.UUID("agg1"), 3, "acme")
+
+	ce, err := ToCloudEvent(event)
+	if err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	if ce.Type() != string(testEventType) {
+		t.Error("the type should be correct:", ce.Type())
+	}
+	if ce.Source() != string(testAggregateType) {
+		t.Error("the source should be correct:", ce.Source())
+	}
+	if ce.Subject() != "agg1" {
+		t.Error("the subject should be correct:", ce.Subject())
+	}
+
+	roundTripped, err := FromCloudEvent(ce)
+	if err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	if roundTripped.EventType() != event.EventType() {
+		t.Error("the event type should round-trip:", roundTripped.EventType())
+	}
+	if roundTripped.AggregateType() != event.AggregateType() {
+		t.Error("the aggregate type should round-trip:", roundTripped.AggregateType())
+	}
+	if roundTripped.AggregateID() != event.AggregateID() {
+		t.Error("the aggregate id should round-trip:", roundTripped.AggregateID())
+	}
+	if roundTripped.Version() != event.Version() {
+		t.Error("the version should round-trip:", roundTripped.Version())
+	}
+	tenanted, ok := roundTripped.(interface{ Tenant() string })
+	if !ok || tenanted.Tenant() != "acme" {
+		t.Error("the tenant should round-trip")
+	}
+	data, ok := roundTripped.Data().(*testEventData)
+	if !ok {
+		t.Fatalf("wrong data type: %T", roundTripped.Data())
+	}
+	if data.Content != "event1" {
+		t.Error("the data should round-trip:", data.Content)
+	}
+}
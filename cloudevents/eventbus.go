@@ -0,0 +1,184 @@
+// Copyright (c) 2019 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudevents
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// EventBus is an eh.EventBus that receives events as POSTed CloudEvents over
+// HTTP, so that non-eventhorizon producers (Knative, Pub/Sub push
+// subscriptions, Kafka ingress via a CloudEvents bridge, ...) can dispatch
+// directly into eventhorizon handlers and observers.
+//
+// PublishEvent is not meaningful for inbound delivery and always returns
+// without effect; use OutboundSender to send events as CloudEvents.
+type EventBus struct {
+	handlersMu sync.RWMutex
+	handlers   map[eh.EventType][]eh.EventHandler
+	observers  []eh.EventObserver
+	strategy   eh.EventHandlingStrategy
+	errCh      chan<- error
+
+	server *http.Server
+}
+
+// NewEventBus creates an EventBus that listens for CloudEvents on addr.
+// Call Start to begin serving.
+func NewEventBus(addr string) *EventBus {
+	b := &EventBus{
+		handlers: make(map[eh.EventType][]eh.EventHandler),
+		strategy: eh.SyncEventHandlingStrategy,
+	}
+	b.server = &http.Server{Addr: addr, Handler: http.HandlerFunc(b.serveHTTP)}
+	return b
+}
+
+// Start begins serving CloudEvents in a new goroutine. Errors from the
+// underlying HTTP server, other than http.ErrServerClosed on Close, are
+// sent on the returned channel.
+func (b *EventBus) Start() <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		if err := b.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+	return errCh
+}
+
+// Close shuts down the HTTP server, waiting for in-flight requests to finish.
+func (b *EventBus) Close(ctx context.Context) error {
+	return b.server.Shutdown(ctx)
+}
+
+func (b *EventBus) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	ce, err := cloudevents.NewEventFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, err := FromCloudEvent(*ce)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b.handlersMu.RLock()
+	strategy := b.strategy
+	b.handlersMu.RUnlock()
+
+	if strategy == eh.AsyncEventHandlingStrategy {
+		// The request is already being accepted; a handler error surfaces
+		// on the error channel instead of the HTTP response.
+		go func() {
+			if err := b.dispatch(event); err != nil {
+				b.reportError(err)
+			}
+		}()
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := b.dispatch(event); err != nil {
+		b.reportError(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// dispatch calls every handler in order, stopping and returning the first
+// error, then notifies all observers, mirroring gcppubsub.EventBus.dispatch.
+func (b *EventBus) dispatch(event eh.Event) error {
+	b.handlersMu.RLock()
+	handlers := append([]eh.EventHandler{}, b.handlers[event.EventType()]...)
+	observers := append([]eh.EventObserver{}, b.observers...)
+	b.handlersMu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler.HandleEvent(event); err != nil {
+			return err
+		}
+	}
+	for _, observer := range observers {
+		observer.Notify(event)
+	}
+	return nil
+}
+
+func (b *EventBus) reportError(err error) {
+	b.handlersMu.RLock()
+	errCh := b.errCh
+	b.handlersMu.RUnlock()
+
+	if errCh == nil {
+		return
+	}
+	select {
+	case errCh <- err:
+	default:
+	}
+}
+
+// PublishEvent implements the PublishEvent method of the eh.EventBus
+// interface. Inbound buses do not publish; pair with OutboundSender to
+// send events out as CloudEvents.
+func (b *EventBus) PublishEvent(event eh.Event) {}
+
+// AddHandler implements the AddHandler method of the eh.EventBus interface.
+func (b *EventBus) AddHandler(handler eh.EventHandler, eventType eh.EventType) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// AddObserver implements the AddObserver method of the eh.EventBus interface.
+func (b *EventBus) AddObserver(observer eh.EventObserver) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.observers = append(b.observers, observer)
+}
+
+// SetHandlingStrategy implements the SetHandlingStrategy method of the
+// eh.EventBus interface. Under AsyncEventHandlingStrategy the request is
+// acknowledged before handlers run, so a handler error can no longer fail
+// the HTTP response; configure SetErrorChannel to observe it instead.
+func (b *EventBus) SetHandlingStrategy(strategy eh.EventHandlingStrategy) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.strategy = strategy
+}
+
+// SetErrorChannel sets the channel on which handler errors are reported.
+// Under SyncEventHandlingStrategy a handler error is reported here and also
+// fails the HTTP response; under AsyncEventHandlingStrategy this channel is
+// the only place it surfaces. It is safe to call at any time.
+func (b *EventBus) SetErrorChannel(errCh chan<- error) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.errCh = errCh
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2019 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudevents
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// funcHandler adapts a plain func to the eh.EventHandler interface, since
+// there is no such adapter in the eventhorizon package itself (its
+// EventHandlerFunc is the ctx-aware shape used by the policy middlewares).
+type funcHandler func(eh.Event) error
+
+func (f funcHandler) HandleEvent(event eh.Event) error { return f(event) }
+
+func newTestRequest(t *testing.T) *httptest.ResponseRecorder {
+	t.Helper()
+	return httptest.NewRecorder()
+}
+
+func postTestEvent(t *testing.T, bus *EventBus) *httptest.ResponseRecorder {
+	t.Helper()
+
+	event := eh.NewEventForAggregate(testEventType, &testEventData{Content: "event1"},
+		time.Now().UTC(), testAggregateType, eh.UUID("agg1"), 1, "")
+	ce, err := ToCloudEvent(event)
+	if err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+
+	body, err := json.Marshal(ce)
+	if err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+
+	w := newTestRequest(t)
+	bus.serveHTTP(w, req)
+	return w
+}
+
+func TestEventBusServeHTTPHandlerError(t *testing.T) {
+	bus := NewEventBus(":0")
+	bus.AddHandler(funcHandler(func(event eh.Event) error {
+		return errors.New("handler error")
+	}), testEventType)
+
+	w := postTestEvent(t, bus)
+	if w.Code != 500 {
+		t.Error("a synchronous handler error should fail the HTTP response:", w.Code)
+	}
+}
+
+func TestEventBusServeHTTPAsyncHandlerErrorReported(t *testing.T) {
+	bus := NewEventBus(":0")
+	bus.SetHandlingStrategy(eh.AsyncEventHandlingStrategy)
+
+	errCh := make(chan error, 1)
+	bus.SetErrorChannel(errCh)
+	bus.AddHandler(funcHandler(func(event eh.Event) error {
+		return errors.New("handler error")
+	}), testEventType)
+
+	w := postTestEvent(t, bus)
+	if w.Code != 200 {
+		t.Error("an async request should already be acknowledged:", w.Code)
+	}
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Error("the reported error should not be nil")
+		}
+	case <-time.After(time.Second):
+		t.Error("the async handler error should have been reported on the error channel")
+	}
+}
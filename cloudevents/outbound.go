@@ -0,0 +1,68 @@
+// Copyright (c) 2019 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudevents
+
+import (
+	"context"
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// OutboundSender observes a local event bus and forwards every event it
+// sees to a set of HTTP endpoints as a CloudEvent, so that external
+// consumers can be wired up without changing how the event is published
+// in-process.
+type OutboundSender struct {
+	endpoints []string
+	client    cloudevents.Client
+}
+
+// NewOutboundSender creates an OutboundSender that POSTs to the given
+// endpoint URLs and registers itself as an observer on bus.
+func NewOutboundSender(bus interface {
+	AddObserver(eh.EventObserver)
+}, endpoints ...string) (*OutboundSender, error) {
+	client, err := cloudevents.NewClientHTTP()
+	if err != nil {
+		return nil, fmt.Errorf("cloudevents: could not create client: %w", err)
+	}
+
+	s := &OutboundSender{
+		endpoints: endpoints,
+		client:    client,
+	}
+	bus.AddObserver(s)
+	return s, nil
+}
+
+// Notify implements the eh.EventObserver interface, converting the event to
+// a CloudEvent and sending it to every configured endpoint. Send errors are
+// swallowed per-endpoint so that one unreachable consumer does not block
+// delivery to the others; callers that need delivery guarantees should pair
+// this with their own retry/outbox handling.
+func (s *OutboundSender) Notify(event eh.Event) {
+	ce, err := ToCloudEvent(event)
+	if err != nil {
+		return
+	}
+
+	for _, endpoint := range s.endpoints {
+		ctx := cloudevents.ContextWithTarget(context.Background(), endpoint)
+		s.client.Send(ctx, ce)
+	}
+}
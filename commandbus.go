@@ -0,0 +1,60 @@
+// Copyright (c) 2020 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import "context"
+
+// CommandBus dispatches commands to the aggregate loaded from a Repository,
+// running them through a chain of CommandHandlerMiddleware first, e.g. the
+// authorization middleware from NewAuthorizationCommandMiddleware. Without
+// it, the command middlewares have nothing concrete to attach to.
+type CommandBus struct {
+	repository Repository
+	middleware []CommandHandlerMiddleware
+}
+
+// NewCommandBus creates a CommandBus dispatching against repository.
+func NewCommandBus(repository Repository) *CommandBus {
+	return &CommandBus{repository: repository}
+}
+
+// Use appends middleware to the chain every command is run through, in the
+// order given: the first middleware added is the outermost, and sees the
+// command before any of the others.
+func (b *CommandBus) Use(middleware ...CommandHandlerMiddleware) {
+	b.middleware = append(b.middleware, middleware...)
+}
+
+// HandleCommand loads the command's target aggregate, runs it through the
+// configured middleware chain and, if the chain lets it through, calls
+// HandleCommand on the aggregate and saves it back to the repository.
+func (b *CommandBus) HandleCommand(ctx context.Context, cmd Command) error {
+	handler := CommandHandlerFunc(func(ctx context.Context, cmd Command) error {
+		agg, err := b.repository.Load(ctx, cmd.AggregateType(), cmd.AggregateID())
+		if err != nil {
+			return err
+		}
+		if err := agg.HandleCommand(cmd); err != nil {
+			return err
+		}
+		return b.repository.Save(ctx, agg)
+	})
+
+	for i := len(b.middleware) - 1; i >= 0; i-- {
+		handler = b.middleware[i](handler)
+	}
+
+	return handler(ctx, cmd)
+}
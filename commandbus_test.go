@@ -0,0 +1,66 @@
+// Copyright (c) 2020 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type mockAggregateRepository struct {
+	Aggregates map[UUID]Aggregate
+}
+
+func (m *mockAggregateRepository) Load(ctx context.Context, aggregateType AggregateType, id UUID) (Aggregate, error) {
+	agg, ok := m.Aggregates[id]
+	if !ok {
+		return nil, errors.New("aggregate not found")
+	}
+	return agg, nil
+}
+
+func (m *mockAggregateRepository) Save(ctx context.Context, agg Aggregate) error {
+	m.Aggregates[agg.AggregateID()] = agg
+	return nil
+}
+
+func TestCommandBusDispatchesThroughMiddleware(t *testing.T) {
+	id := UUID("id1")
+	repo := &mockAggregateRepository{Aggregates: map[UUID]Aggregate{
+		id: NewTestAggregate(id),
+	}}
+
+	store := NewPolicyStore()
+	store.Register(Policy{From: []string{"admin"}, To: []string{string(TestAggregateType)}})
+
+	bus := NewCommandBus(repo)
+	bus.Use(NewAuthorizationCommandMiddleware(store))
+
+	cmd := &TestCommand{TestID: id, Content: "hello"}
+
+	err := bus.HandleCommand(WithIdentity(context.Background(), "someone-else"), cmd)
+	if _, ok := err.(ErrUnauthorized); !ok {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+
+	if err := bus.HandleCommand(WithIdentity(context.Background(), "admin"), cmd); err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	agg := repo.Aggregates[id].(*TestAggregate)
+	if agg.numHandled != 1 {
+		t.Error("the aggregate should have handled the command:", agg.numHandled)
+	}
+}
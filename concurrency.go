@@ -0,0 +1,35 @@
+// Copyright (c) 2016 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import "fmt"
+
+// ErrAggregateVersionMismatch is returned by EventStore.Save when the
+// originalVersion passed by the caller no longer matches the version at the
+// head of the stored event stream, meaning another writer committed events
+// to the same aggregate in the meantime. Callers should treat it as
+// retryable: reload the aggregate, re-apply the command and save again.
+type ErrAggregateVersionMismatch struct {
+	// OriginalVersion is the version the caller believed the aggregate was at.
+	OriginalVersion int
+	// ActualVersion is the version currently at the head of the stream.
+	ActualVersion int
+}
+
+// Error implements the error interface.
+func (e ErrAggregateVersionMismatch) Error() string {
+	return fmt.Sprintf("eventhorizon: aggregate version mismatch, expected %d but was %d",
+		e.OriginalVersion, e.ActualVersion)
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2016 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import "testing"
+
+func TestAggregateBaseMultipleUncommittedEvents(t *testing.T) {
+	agg := NewTestAggregate(UUID("id1"))
+
+	e1 := agg.NewEvent(TestEventType, &TestEventData{"a"})
+	agg.StoreEvent(e1)
+	e2 := agg.NewEvent(TestEventType, &TestEventData{"b"})
+	agg.StoreEvent(e2)
+
+	if e1.Version() == e2.Version() {
+		t.Error("uncommitted events should not collide on the same version:", e1.Version(), e2.Version())
+	}
+	if e1.Version() != 1 || e2.Version() != 2 {
+		t.Error("events should be stamped with consecutive versions:", e1.Version(), e2.Version())
+	}
+	if agg.Version() != 0 {
+		t.Error("the committed version should be unchanged until events are cleared:", agg.Version())
+	}
+
+	agg.ClearUncommittedEvents()
+	if agg.Version() != 2 {
+		t.Error("the committed version should advance by the number of committed events:", agg.Version())
+	}
+
+	e3 := agg.NewEvent(TestEventType, &TestEventData{"c"})
+	if e3.Version() != 3 {
+		t.Error("the next event should continue from the new committed version:", e3.Version())
+	}
+}
+
+func TestMockEventStoreVersionMismatch(t *testing.T) {
+	store := &MockEventStore{}
+
+	e := NewEvent(TestEventType, &TestEventData{"a"})
+	if err := store.Save([]Event{e}, 0); err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+
+	if err := store.Save([]Event{e}, 0); err == nil {
+		t.Fatal("there should be a version mismatch error")
+	} else if mismatch, ok := err.(ErrAggregateVersionMismatch); !ok {
+		t.Errorf("the error should be an ErrAggregateVersionMismatch: %T", err)
+	} else if mismatch.OriginalVersion != 0 || mismatch.ActualVersion != 1 {
+		t.Error("the mismatch should report both versions:", mismatch)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2019 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import "time"
+
+// NewEventForAggregate creates an event with all fields set explicitly,
+// rather than stamping the aggregate reference, version and timestamp from
+// an in-flight AggregateBase as NewEvent does. It is intended for adapters,
+// such as the cloudevents package, that reconstruct an eventhorizon Event
+// from an external representation which already carries these values.
+// tenant may be left empty for single-tenant deployments.
+func NewEventForAggregate(eventType EventType, data EventData, timestamp time.Time,
+	aggregateType AggregateType, aggregateID UUID, version int, tenant string) Event {
+	e := NewEvent(eventType, data)
+	evt, ok := e.(event)
+	if !ok {
+		return e
+	}
+	evt.timestamp = timestamp
+	evt.aggregateType = aggregateType
+	evt.aggregateID = aggregateID
+	evt.version = version
+	evt.tenant = tenant
+	return evt
+}
+
+// Tenant returns the tenant the event's aggregate belongs to, or "" for
+// single-tenant deployments.
+func (e event) Tenant() string {
+	return e.tenant
+}
@@ -0,0 +1,297 @@
+// Copyright (c) 2019 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcppubsub provides an eh.EventBus implementation backed by
+// Google Cloud Pub/Sub.
+package gcppubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// EventBus is an eh.EventBus backed by a Google Cloud Pub/Sub topic and
+// subscription. Published events are marshaled to JSON with event metadata
+// carried as message attributes; received messages are decoded back into
+// events via the eh.EventData registry and fanned out to handlers and
+// observers by a pool of receiver goroutines.
+type EventBus struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
+	sub    *pubsub.Subscription
+
+	handlersMu sync.RWMutex
+	handlers   map[eh.EventType][]eh.EventHandler
+	observers  []eh.EventObserver
+	strategy   eh.EventHandlingStrategy
+	errCh      chan<- error
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// message is the JSON payload published to Pub/Sub; event metadata needed
+// to decode and dispatch it is carried separately as message attributes.
+type message struct {
+	Data json.RawMessage `json:"data"`
+}
+
+// NewEventBus creates an EventBus using the given GCP project, creating the
+// topic and subscription if they do not already exist. It does not start
+// receiving messages; call Start once handlers, observers and the error
+// channel have been configured.
+func NewEventBus(ctx context.Context, projectID, topicID, subscriptionID string) (*EventBus, error) {
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("gcppubsub: could not create client: %w", err)
+	}
+
+	topic := client.Topic(topicID)
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("gcppubsub: could not check topic: %w", err)
+	}
+	if !exists {
+		if topic, err = client.CreateTopic(ctx, topicID); err != nil {
+			return nil, fmt.Errorf("gcppubsub: could not create topic: %w", err)
+		}
+	}
+
+	sub := client.Subscription(subscriptionID)
+	exists, err = sub.Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("gcppubsub: could not check subscription: %w", err)
+	}
+	if !exists {
+		if sub, err = client.CreateSubscription(ctx, subscriptionID, pubsub.SubscriptionConfig{Topic: topic}); err != nil {
+			return nil, fmt.Errorf("gcppubsub: could not create subscription: %w", err)
+		}
+	}
+
+	b := &EventBus{
+		client:   client,
+		topic:    topic,
+		sub:      sub,
+		handlers: make(map[eh.EventType][]eh.EventHandler),
+		strategy: eh.SyncEventHandlingStrategy,
+	}
+
+	return b, nil
+}
+
+// Start begins receiving messages on a background goroutine. Handlers,
+// observers and the error channel should be configured before calling Start,
+// since messages may start being delivered as soon as it returns.
+func (b *EventBus) Start(ctx context.Context) {
+	receiveCtx, cancel := context.WithCancel(ctx)
+	b.cancel = cancel
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		// Receive blocks, running b.receive for each message on an
+		// internally managed goroutine pool, until receiveCtx is canceled.
+		if err := b.sub.Receive(receiveCtx, b.receive); err != nil && receiveCtx.Err() == nil {
+			b.reportError(fmt.Errorf("gcppubsub: receive stopped: %w", err))
+		}
+	}()
+}
+
+// PublishEvent implements the PublishEvent method of the eh.EventBus interface.
+func (b *EventBus) PublishEvent(event eh.Event) {
+	data, err := json.Marshal(event.Data())
+	if err != nil {
+		b.reportError(fmt.Errorf("gcppubsub: could not marshal event data: %w", err))
+		return
+	}
+
+	payload, err := json.Marshal(message{Data: data})
+	if err != nil {
+		b.reportError(fmt.Errorf("gcppubsub: could not marshal message: %w", err))
+		return
+	}
+
+	result := b.topic.Publish(context.Background(), &pubsub.Message{
+		Data: payload,
+		Attributes: map[string]string{
+			"event_type":     string(event.EventType()),
+			"aggregate_type": string(event.AggregateType()),
+			"aggregate_id":   string(event.AggregateID()),
+			"version":        strconv.Itoa(event.Version()),
+			"tenant":         tenant(event),
+		},
+	})
+
+	if _, err := result.Get(context.Background()); err != nil {
+		b.reportError(fmt.Errorf("gcppubsub: could not publish event: %w", err))
+	}
+}
+
+func (b *EventBus) receive(ctx context.Context, msg *pubsub.Message) {
+	event, err := b.decode(msg)
+	if err != nil {
+		b.reportError(fmt.Errorf("gcppubsub: could not decode message: %w", err))
+		msg.Nack()
+		return
+	}
+
+	b.handlersMu.RLock()
+	handlers := append([]eh.EventHandler{}, b.handlers[event.EventType()]...)
+	observers := append([]eh.EventObserver{}, b.observers...)
+	strategy := b.strategy
+	b.handlersMu.RUnlock()
+
+	if strategy == eh.AsyncEventHandlingStrategy {
+		msg.Ack()
+		go b.dispatch(event, handlers, observers)
+		return
+	}
+
+	if err := b.dispatch(event, handlers, observers); err != nil {
+		msg.Nack()
+		return
+	}
+	msg.Ack()
+}
+
+func (b *EventBus) decode(msg *pubsub.Message) (eh.Event, error) {
+	var m message
+	if err := json.Unmarshal(msg.Data, &m); err != nil {
+		return nil, err
+	}
+
+	eventType := eh.EventType(msg.Attributes["event_type"])
+	data, err := eh.CreateEventData(eventType)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(m.Data, data); err != nil {
+		return nil, err
+	}
+
+	version, _ := strconv.Atoi(msg.Attributes["version"])
+
+	return eh.NewEventForAggregate(eventType, data, msg.PublishTime,
+		eh.AggregateType(msg.Attributes["aggregate_type"]),
+		eh.UUID(msg.Attributes["aggregate_id"]),
+		version,
+		msg.Attributes["tenant"]), nil
+}
+
+// tenant extracts the tenant from an event that carries one, returning ""
+// for single-tenant events.
+func tenant(event eh.Event) string {
+	if t, ok := event.(interface{ Tenant() string }); ok {
+		return t.Tenant()
+	}
+	return ""
+}
+
+// dispatch calls every handler in order, stopping and returning the first
+// error, then notifies all observers. Under SyncEventHandlingStrategy the
+// returned error determines whether the Pub/Sub message is acked or nacked.
+func (b *EventBus) dispatch(event eh.Event, handlers []eh.EventHandler, observers []eh.EventObserver) error {
+	for _, handler := range handlers {
+		if err := handler.HandleEvent(event); err != nil {
+			b.reportError(fmt.Errorf("gcppubsub: handler error for %s: %w", event.EventType(), err))
+			return err
+		}
+	}
+	for _, observer := range observers {
+		observer.Notify(event)
+	}
+	return nil
+}
+
+func (b *EventBus) reportError(err error) {
+	b.handlersMu.RLock()
+	errCh := b.errCh
+	b.handlersMu.RUnlock()
+
+	if errCh == nil {
+		return
+	}
+	select {
+	case errCh <- err:
+	default:
+	}
+}
+
+// AddHandler implements the AddHandler method of the eh.EventBus interface.
+func (b *EventBus) AddHandler(handler eh.EventHandler, eventType eh.EventType) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// AddObserver implements the AddObserver method of the eh.EventBus interface.
+func (b *EventBus) AddObserver(observer eh.EventObserver) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.observers = append(b.observers, observer)
+}
+
+// SetHandlingStrategy implements the SetHandlingStrategy method of the
+// eh.EventBus interface.
+func (b *EventBus) SetHandlingStrategy(strategy eh.EventHandlingStrategy) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.strategy = strategy
+}
+
+// SetErrorChannel sets the channel on which errors encountered while
+// receiving or handling events are reported. It should be called before
+// Start, so that no early errors are dropped, but is safe to call at any
+// time since every read of the channel is synchronized with handlersMu.
+func (b *EventBus) SetErrorChannel(errCh chan<- error) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.errCh = errCh
+}
+
+// Close stops receiving new messages and waits for in-flight ones to
+// finish, then closes the underlying Pub/Sub client. Under
+// AsyncEventHandlingStrategy, messages are acked before their handlers run,
+// and handlers already started on their own goroutines are not waited for.
+// It is safe to call even if Start was never called.
+func (b *EventBus) Close(ctx context.Context) error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return b.client.Close()
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2019 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcppubsub
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/looplab/eventhorizon/eventbus/testutil"
+)
+
+// TestEventBus runs the common event bus test suite against a real Pub/Sub
+// emulator. It is skipped unless PUBSUB_EMULATOR_HOST is set, since there is
+// no in-memory fake for the Pub/Sub client.
+func TestEventBus(t *testing.T) {
+	if os.Getenv("PUBSUB_EMULATOR_HOST") == "" {
+		t.Skip("no Pub/Sub emulator configured, set PUBSUB_EMULATOR_HOST to run")
+	}
+
+	ctx := context.Background()
+	bus, err := NewEventBus(ctx, "eventhorizon-test", "events", "events-sub")
+	if err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	defer bus.Close(ctx)
+	bus.Start(ctx)
+
+	testutil.EventBusCommonTests(t, bus, bus)
+}
+
+func TestEventBusAsync(t *testing.T) {
+	if os.Getenv("PUBSUB_EMULATOR_HOST") == "" {
+		t.Skip("no Pub/Sub emulator configured, set PUBSUB_EMULATOR_HOST to run")
+	}
+
+	ctx := context.Background()
+	bus, err := NewEventBus(ctx, "eventhorizon-test", "events-async", "events-async-sub")
+	if err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	defer bus.Close(ctx)
+
+	bus.SetHandlingStrategy(eh.AsyncEventHandlingStrategy)
+	bus.Start(ctx)
+
+	testutil.EventBusCommonTests(t, bus, bus)
+}
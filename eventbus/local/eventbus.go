@@ -0,0 +1,105 @@
+// Copyright (c) 2014 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package local provides an in-process eh.EventBus, useful for tests and
+// single-process deployments.
+package local
+
+import (
+	"sync"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// EventBus is an in-process event bus that notifies registered handlers and
+// observers of published events, either synchronously or asynchronously
+// depending on the configured EventHandlingStrategy.
+type EventBus struct {
+	handlersMu sync.RWMutex
+	handlers   map[eh.EventType][]eh.EventHandler
+	observers  []eh.EventObserver
+	strategy   eh.EventHandlingStrategy
+
+	// errCh receives handler errors under either handling strategy.
+	errCh chan error
+}
+
+// NewEventBus creates an EventBus using SyncEventHandlingStrategy by default.
+func NewEventBus() *EventBus {
+	return &EventBus{
+		handlers: make(map[eh.EventType][]eh.EventHandler),
+		strategy: eh.SyncEventHandlingStrategy,
+		errCh:    make(chan error, 20),
+	}
+}
+
+// PublishEvent implements the PublishEvent method of the eh.EventBus interface.
+func (b *EventBus) PublishEvent(event eh.Event) {
+	b.handlersMu.RLock()
+	handlers := append([]eh.EventHandler{}, b.handlers[event.EventType()]...)
+	observers := append([]eh.EventObserver{}, b.observers...)
+	strategy := b.strategy
+	b.handlersMu.RUnlock()
+
+	if strategy == eh.AsyncEventHandlingStrategy {
+		go b.handle(event, handlers, observers)
+		return
+	}
+	b.handle(event, handlers, observers)
+}
+
+func (b *EventBus) handle(event eh.Event, handlers []eh.EventHandler, observers []eh.EventObserver) {
+	for _, handler := range handlers {
+		if err := handler.HandleEvent(event); err != nil {
+			select {
+			case b.errCh <- err:
+			default:
+			}
+		}
+	}
+	for _, observer := range observers {
+		observer.Notify(event)
+	}
+}
+
+// AddHandler implements the AddHandler method of the eh.EventBus interface.
+func (b *EventBus) AddHandler(handler eh.EventHandler, eventType eh.EventType) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// AddObserver implements the AddObserver method of the eh.EventBus interface.
+func (b *EventBus) AddObserver(observer eh.EventObserver) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.observers = append(b.observers, observer)
+}
+
+// SetHandlingStrategy implements the SetHandlingStrategy method of the
+// eh.EventBus interface.
+func (b *EventBus) SetHandlingStrategy(strategy eh.EventHandlingStrategy) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.strategy = strategy
+}
+
+// Errors returns the channel on which handler errors are reported, under
+// both SyncEventHandlingStrategy and AsyncEventHandlingStrategy.
+func (b *EventBus) Errors() <-chan error {
+	return b.errCh
+}
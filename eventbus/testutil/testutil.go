@@ -0,0 +1,124 @@
+// Copyright (c) 2016 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutil provides a common test suite that every eh.EventBus
+// implementation can run against itself to verify basic conformance.
+package testutil
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// testEventType and testEvent2Type are registered here rather than reused
+// from eh's own test fixtures, since those live in a _test.go file and are
+// invisible to this package (and to everything that imports it).
+const testEventType eh.EventType = "testutil.TestEvent"
+const testEvent2Type eh.EventType = "testutil.TestEvent2"
+
+type testEventData struct {
+	Content string
+}
+
+type testEvent2Data struct {
+	Content string
+}
+
+func init() {
+	eh.RegisterEventData(testEventType, func() eh.EventData { return &testEventData{} })
+	eh.RegisterEventData(testEvent2Type, func() eh.EventData { return &testEvent2Data{} })
+}
+
+type testEventHandler struct {
+	mu     sync.Mutex
+	events []eh.Event
+}
+
+func (h *testEventHandler) HandleEvent(event eh.Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+	return nil
+}
+
+func (h *testEventHandler) seen() []eh.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]eh.Event{}, h.events...)
+}
+
+type testEventObserver struct {
+	mu     sync.Mutex
+	events []eh.Event
+}
+
+func (o *testEventObserver) Notify(event eh.Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, event)
+}
+
+func (o *testEventObserver) seen() []eh.Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]eh.Event{}, o.events...)
+}
+
+// EventBusCommonTests runs a common test suite against an eh.EventBus
+// implementation. publisher and subscriber may be the same bus instance
+// (for in-process buses) or two handles onto the same underlying transport
+// (for networked buses), so that subscriptions registered on one side are
+// observed from events published on the other.
+func EventBusCommonTests(t *testing.T, publisher, subscriber eh.EventBus) {
+	handler := &testEventHandler{}
+	observer := &testEventObserver{}
+	subscriber.AddHandler(handler, testEventType)
+	subscriber.AddObserver(observer)
+
+	event := eh.NewEvent(testEventType, &testEventData{Content: "event1"})
+	publisher.PublishEvent(event)
+
+	if !waitFor(func() bool { return len(handler.seen()) == 1 }) {
+		t.Error("the handler should have received the event")
+	}
+	if !waitFor(func() bool { return len(observer.seen()) == 1 }) {
+		t.Error("the observer should have received the event")
+	}
+
+	otherTypeEvent := eh.NewEvent(testEvent2Type, &testEvent2Data{Content: "event2"})
+	publisher.PublishEvent(otherTypeEvent)
+
+	if !waitFor(func() bool { return len(observer.seen()) == 2 }) {
+		t.Error("the observer should receive events regardless of type")
+	}
+	if len(handler.seen()) != 1 {
+		t.Error("the handler should not receive events of other types:", len(handler.seen()))
+	}
+}
+
+// waitFor polls cond for a short time to account for asynchronous delivery
+// under AsyncEventHandlingStrategy, and returns whether it became true.
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
@@ -49,7 +49,7 @@ type TestAggregate struct {
 
 func NewTestAggregate(id UUID) *TestAggregate {
 	return &TestAggregate{
-		AggregateBase: NewAggregateBase(TestAggregateType, id),
+		AggregateBase: NewAggregateBase(TestAggregateType, id, ""),
 	}
 }
 
@@ -84,7 +84,7 @@ type TestAggregate2 struct {
 
 func NewTestAggregate2(id UUID) *TestAggregate2 {
 	return &TestAggregate2{
-		AggregateBase: NewAggregateBase(TestAggregate2Type, id),
+		AggregateBase: NewAggregateBase(TestAggregate2Type, id, ""),
 	}
 }
 
@@ -157,6 +157,12 @@ func (m *MockEventStore) Save(events []Event, originalVersion int) error {
 	if m.err != nil {
 		return m.err
 	}
+	if originalVersion != len(m.Events) {
+		return ErrAggregateVersionMismatch{
+			OriginalVersion: originalVersion,
+			ActualVersion:   len(m.Events),
+		}
+	}
 	for _, event := range events {
 		m.Events = append(m.Events, event)
 	}
@@ -0,0 +1,91 @@
+// Copyright (c) 2016 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memory provides an in-memory eh.EventStore, useful for tests and
+// single-process deployments.
+package memory
+
+import (
+	"context"
+	"sync"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// streamKey scopes an aggregate's event stream to a tenant (empty for
+// single-tenant deployments), mirroring snapshotstore/memory's snapshotKey.
+type streamKey struct {
+	tenant        string
+	aggregateType eh.AggregateType
+	id            eh.UUID
+}
+
+// EventStore implements eh.EventStore and eh.TenantEventStore by keeping
+// every aggregate's event stream in memory. It is safe for concurrent use
+// and enforces optimistic concurrency: a save whose originalVersion does not
+// match the current length of the stored stream is rejected with
+// eh.ErrAggregateVersionMismatch.
+type EventStore struct {
+	streamsMu sync.Mutex
+	streams   map[streamKey][]eh.Event
+}
+
+// NewEventStore creates a new EventStore.
+func NewEventStore() *EventStore {
+	return &EventStore{
+		streams: make(map[streamKey][]eh.Event),
+	}
+}
+
+// Load implements the Load method of the eh.EventStore interface.
+func (s *EventStore) Load(aggregateType eh.AggregateType, id eh.UUID) ([]eh.Event, error) {
+	return s.LoadStream(context.Background(), "", aggregateType, id)
+}
+
+// Save implements the Save method of the eh.EventStore interface.
+func (s *EventStore) Save(events []eh.Event, originalVersion int) error {
+	return s.SaveStream(context.Background(), "", events, originalVersion)
+}
+
+// LoadStream implements the LoadStream method of the eh.TenantEventStore interface.
+func (s *EventStore) LoadStream(ctx context.Context, tenant string, aggregateType eh.AggregateType, id eh.UUID) ([]eh.Event, error) {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+
+	return append([]eh.Event{}, s.streams[streamKey{tenant, aggregateType, id}]...), nil
+}
+
+// SaveStream implements the SaveStream method of the eh.TenantEventStore
+// interface, rejecting the save with eh.ErrAggregateVersionMismatch if
+// originalVersion does not match the current length of the stored stream.
+func (s *EventStore) SaveStream(ctx context.Context, tenant string, events []eh.Event, originalVersion int) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+
+	key := streamKey{tenant, events[0].AggregateType(), events[0].AggregateID()}
+	stream := s.streams[key]
+	if originalVersion != len(stream) {
+		return eh.ErrAggregateVersionMismatch{
+			OriginalVersion: originalVersion,
+			ActualVersion:   len(stream),
+		}
+	}
+
+	s.streams[key] = append(stream, events...)
+	return nil
+}
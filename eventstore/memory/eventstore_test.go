@@ -0,0 +1,109 @@
+// Copyright (c) 2016 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+const testEventType eh.EventType = "memoryEventStoreTestEvent"
+const testAggregateType eh.AggregateType = "memoryEventStoreTestAggregate"
+
+func init() {
+	eh.RegisterEventData(testEventType, func() eh.EventData { return &testEventData{} })
+}
+
+type testEventData struct {
+	Content string
+}
+
+func newTestEvent(id eh.UUID, content string, version int) eh.Event {
+	return eh.NewEventForAggregate(testEventType, &testEventData{Content: content},
+		time.Now().UTC(), testAggregateType, id, version, "")
+}
+
+func TestEventStoreSaveAndLoad(t *testing.T) {
+	store := NewEventStore()
+	id := eh.UUID("id1")
+
+	if err := store.Save([]eh.Event{newTestEvent(id, "a", 1)}, 0); err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	if err := store.Save([]eh.Event{newTestEvent(id, "b", 2)}, 1); err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+
+	events, err := store.Load(testAggregateType, id)
+	if err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	if len(events) != 2 {
+		t.Fatal("there should be two events:", len(events))
+	}
+	if events[0].Data().(*testEventData).Content != "a" || events[1].Data().(*testEventData).Content != "b" {
+		t.Error("the events should be returned in the order they were saved:", events)
+	}
+}
+
+func TestEventStoreRejectsVersionMismatch(t *testing.T) {
+	store := NewEventStore()
+	id := eh.UUID("id1")
+
+	if err := store.Save([]eh.Event{newTestEvent(id, "a", 1)}, 0); err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+
+	err := store.Save([]eh.Event{newTestEvent(id, "b", 2)}, 0)
+	if err == nil {
+		t.Fatal("there should be a version mismatch error")
+	}
+	mismatch, ok := err.(eh.ErrAggregateVersionMismatch)
+	if !ok {
+		t.Fatalf("the error should be an eh.ErrAggregateVersionMismatch: %T", err)
+	}
+	if mismatch.OriginalVersion != 0 || mismatch.ActualVersion != 1 {
+		t.Error("the mismatch should report both versions:", mismatch)
+	}
+}
+
+func TestEventStoreScopesStreamsByTenant(t *testing.T) {
+	store := NewEventStore()
+	id := eh.UUID("id1")
+	ctx := context.Background()
+
+	if err := store.SaveStream(ctx, "acme", []eh.Event{newTestEvent(id, "a", 1)}, 0); err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+
+	events, err := store.LoadStream(ctx, "other", testAggregateType, id)
+	if err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	if len(events) != 0 {
+		t.Error("no events should leak into other tenants:", events)
+	}
+
+	events, err = store.LoadStream(ctx, "acme", testAggregateType, id)
+	if err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	if len(events) != 1 {
+		t.Error("the event should be scoped to its own tenant:", events)
+	}
+}
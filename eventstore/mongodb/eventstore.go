@@ -0,0 +1,229 @@
+// Copyright (c) 2016 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mongodb provides a MongoDB eh.EventStore.
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// defaultTenant is the value stored for the tenant field of documents
+// written before multi-tenancy was introduced, or written for a "" tenant.
+const defaultTenant = eh.DefaultTenant
+
+// EventStore implements eh.EventStore and eh.TenantEventStore using MongoDB,
+// storing one document per event. Optimistic concurrency is enforced by a
+// compound unique index on {tenant, aggregate_type, aggregate_id, version}:
+// a racing writer's bulk insert fails with a duplicate key error if another
+// writer committed to the same stream first, which Save reports as the same
+// eh.ErrAggregateVersionMismatch a non-atomic count check would have caught.
+type EventStore struct {
+	session  *mgo.Session
+	dbPrefix string
+}
+
+// eventDocument is the DB representation of a single stored event.
+type eventDocument struct {
+	Tenant        string           `bson:"tenant"`
+	AggregateType eh.AggregateType `bson:"aggregate_type"`
+	AggregateID   eh.UUID          `bson:"aggregate_id"`
+	Version       int              `bson:"version"`
+	EventType     eh.EventType     `bson:"event_type"`
+	Timestamp     time.Time        `bson:"timestamp"`
+	Data          []byte           `bson:"data"`
+}
+
+// NewEventStore creates a new EventStore with a MongoDB URI and database
+// prefix, following the snapshotstore/mongodb convention of prefixing the
+// "events" collection's database name.
+func NewEventStore(url, dbPrefix string) (*EventStore, error) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	session.SetMode(mgo.Strong, true)
+	session.SetSafe(&mgo.Safe{W: 1})
+
+	return NewEventStoreWithSession(session, dbPrefix), nil
+}
+
+// NewEventStoreWithSession creates a new EventStore with a pre-existing
+// MongoDB session.
+func NewEventStoreWithSession(session *mgo.Session, dbPrefix string) *EventStore {
+	return &EventStore{
+		session:  session,
+		dbPrefix: dbPrefix,
+	}
+}
+
+func (s *EventStore) db() *mgo.Database {
+	return s.session.DB(s.dbPrefix + "_eventhorizon")
+}
+
+func (s *EventStore) collection() *mgo.Collection {
+	sess := s.session.Copy()
+	return s.db().C("events").With(sess)
+}
+
+// Load implements the Load method of the eh.EventStore interface.
+func (s *EventStore) Load(aggregateType eh.AggregateType, id eh.UUID) ([]eh.Event, error) {
+	return s.LoadStream(context.Background(), "", aggregateType, id)
+}
+
+// Save implements the Save method of the eh.EventStore interface.
+func (s *EventStore) Save(events []eh.Event, originalVersion int) error {
+	return s.SaveStream(context.Background(), "", events, originalVersion)
+}
+
+// LoadStream implements the LoadStream method of the eh.TenantEventStore interface.
+func (s *EventStore) LoadStream(ctx context.Context, tenant string, aggregateType eh.AggregateType, id eh.UUID) ([]eh.Event, error) {
+	c := s.collection()
+	defer c.Database.Session.Close()
+
+	var docs []eventDocument
+	if err := c.Find(bson.M{
+		"tenant":         tenantKey(tenant),
+		"aggregate_type": aggregateType,
+		"aggregate_id":   id,
+	}).Sort("version").All(&docs); err != nil {
+		return nil, err
+	}
+
+	events := make([]eh.Event, len(docs))
+	for i, doc := range docs {
+		data, err := eh.CreateEventData(doc.EventType)
+		if err != nil {
+			return nil, err
+		}
+		if err := bson.Unmarshal(doc.Data, data); err != nil {
+			return nil, err
+		}
+		events[i] = eh.NewEventForAggregate(doc.EventType, data, doc.Timestamp,
+			doc.AggregateType, doc.AggregateID, doc.Version, tenant)
+	}
+	return events, nil
+}
+
+// SaveStream implements the SaveStream method of the eh.TenantEventStore
+// interface, rejecting the save with eh.ErrAggregateVersionMismatch if
+// originalVersion does not match the current length of the stored stream.
+func (s *EventStore) SaveStream(ctx context.Context, tenant string, events []eh.Event, originalVersion int) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	c := s.collection()
+	defer c.Database.Session.Close()
+
+	key := tenantKey(tenant)
+	aggregateType := events[0].AggregateType()
+	aggregateID := events[0].AggregateID()
+
+	count, err := c.Find(bson.M{
+		"tenant":         key,
+		"aggregate_type": aggregateType,
+		"aggregate_id":   aggregateID,
+	}).Count()
+	if err != nil {
+		return err
+	}
+	if count != originalVersion {
+		return eh.ErrAggregateVersionMismatch{OriginalVersion: originalVersion, ActualVersion: count}
+	}
+
+	docs := make([]interface{}, len(events))
+	for i, event := range events {
+		data, err := bson.Marshal(event.Data())
+		if err != nil {
+			return err
+		}
+		docs[i] = eventDocument{
+			Tenant:        key,
+			AggregateType: aggregateType,
+			AggregateID:   aggregateID,
+			Version:       event.Version(),
+			EventType:     event.EventType(),
+			Timestamp:     event.Timestamp(),
+			Data:          data,
+		}
+	}
+
+	if err := c.Insert(docs...); err != nil {
+		if mgo.IsDup(err) {
+			// Another writer committed to this stream between the Count
+			// above and this Insert; the unique index caught it for us.
+			// Count again so the error reports the stream's new head.
+			actual, countErr := c.Find(bson.M{
+				"tenant":         key,
+				"aggregate_type": aggregateType,
+				"aggregate_id":   aggregateID,
+			}).Count()
+			if countErr != nil {
+				return countErr
+			}
+			return eh.ErrAggregateVersionMismatch{OriginalVersion: originalVersion, ActualVersion: actual}
+		}
+		return err
+	}
+	return nil
+}
+
+// tenantKey normalizes the empty (single-tenant) tenant to defaultTenant, so
+// that every document in the collection carries a non-empty tenant field
+// and can be indexed and migrated uniformly.
+func tenantKey(tenant string) string {
+	if tenant == "" {
+		return defaultTenant
+	}
+	return tenant
+}
+
+// EnsureIndexes creates the compound index needed to look up a stream by
+// tenant and aggregate and to enforce optimistic concurrency on Save, and
+// should be called once on startup.
+func (s *EventStore) EnsureIndexes() error {
+	c := s.collection()
+	defer c.Database.Session.Close()
+
+	return c.EnsureIndex(mgo.Index{
+		Key:    []string{"tenant", "aggregate_type", "aggregate_id", "version"},
+		Unique: true,
+	})
+}
+
+// MigrateToTenant backfills the tenant field on documents written before
+// multi-tenancy was introduced, assigning them to defaultTenant so they
+// keep resolving for single-tenant callers after the unique index is
+// rebuilt.
+func (s *EventStore) MigrateToTenant() error {
+	c := s.collection()
+	defer c.Database.Session.Close()
+
+	_, err := c.UpdateAll(
+		bson.M{"tenant": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"tenant": defaultTenant}},
+	)
+	return err
+}
+
+// Close closes the underlying MongoDB session.
+func (s *EventStore) Close() {
+	s.session.Close()
+}
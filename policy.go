@@ -0,0 +1,199 @@
+// Copyright (c) 2020 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+)
+
+// identityContextKey is an unexported type to avoid collisions with context
+// keys from other packages, following the same pattern as tenantContextKey.
+type identityContextKey int
+
+const identityKey identityContextKey = 0
+
+// WithIdentity returns a copy of ctx carrying the given subject, so that it
+// can be picked up by a PolicyStore to authorize a command or event, e.g.
+// as set by a command bus from an authenticated request.
+func WithIdentity(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, identityKey, subject)
+}
+
+// IdentityFromContext returns the subject previously attached with
+// WithIdentity, and false if ctx carries none.
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(identityKey).(string)
+	return subject, ok
+}
+
+// Policy declares that the subjects matched by From may act on the
+// aggregate or event types matched by To. Both From and To are lists of
+// glob patterns as understood by path.Match, e.g. "billing.*" or "*".
+type Policy struct {
+	From []string
+	To   []string
+}
+
+// ErrUnauthorized is returned by the authorization middlewares when no
+// registered Policy allows Subject to perform Action against Target.
+type ErrUnauthorized struct {
+	Subject string
+	Action  string
+	Target  string
+}
+
+// Error implements the error interface.
+func (e ErrUnauthorized) Error() string {
+	return fmt.Sprintf("eventhorizon: %q is not authorized to %q %q", e.Subject, e.Action, e.Target)
+}
+
+// PolicyStore holds the set of registered policies and decides whether a
+// subject is allowed to act on a target (an aggregate or event type).
+type PolicyStore interface {
+	// Register adds a policy. Policies are additive: a target is allowed
+	// for a subject if any registered policy matches both.
+	Register(policy Policy)
+
+	// Allowed reports whether subject may act on target.
+	Allowed(subject, target string) bool
+}
+
+// memoryPolicyStore is the default in-memory PolicyStore.
+//
+// A target with no matching policy at all is allowed by default, so that
+// operators only need to register policies for the aggregates and events
+// they actually want to fence off (e.g. billing, admin). Once at least one
+// policy targets it, it is denied unless a matching policy's From also
+// matches the subject.
+type memoryPolicyStore struct {
+	mu       sync.RWMutex
+	policies []Policy
+}
+
+// NewPolicyStore creates an empty in-memory PolicyStore.
+func NewPolicyStore() PolicyStore {
+	return &memoryPolicyStore{}
+}
+
+// Register implements the Register method of the PolicyStore interface.
+func (s *memoryPolicyStore) Register(policy Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.policies = append(s.policies, policy)
+}
+
+// Allowed implements the Allowed method of the PolicyStore interface.
+func (s *memoryPolicyStore) Allowed(subject, target string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	targeted := false
+	for _, p := range s.policies {
+		if !matchesAny(p.To, target) {
+			continue
+		}
+		targeted = true
+		if matchesAny(p.From, subject) {
+			return true
+		}
+	}
+	return !targeted
+}
+
+func matchesAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CommandHandlerFunc handles a command dispatched with a context carrying
+// the caller's identity, e.g. via WithIdentity.
+type CommandHandlerFunc func(ctx context.Context, cmd Command) error
+
+// CommandHandlerMiddleware wraps a CommandHandlerFunc to add cross-cutting
+// behavior such as authorization.
+type CommandHandlerMiddleware func(CommandHandlerFunc) CommandHandlerFunc
+
+// NewAuthorizationCommandMiddleware returns a CommandHandlerMiddleware that
+// consults store with the identity from ctx and the command's aggregate
+// type, denying the command with ErrUnauthorized if it is not allowed.
+func NewAuthorizationCommandMiddleware(store PolicyStore) CommandHandlerMiddleware {
+	return func(next CommandHandlerFunc) CommandHandlerFunc {
+		return func(ctx context.Context, cmd Command) error {
+			subject, _ := IdentityFromContext(ctx)
+			target := string(cmd.AggregateType())
+			if !store.Allowed(subject, target) {
+				return ErrUnauthorized{
+					Subject: subject,
+					Action:  string(cmd.CommandType()),
+					Target:  target,
+				}
+			}
+			return next(ctx, cmd)
+		}
+	}
+}
+
+// EventHandlerFunc handles an event dispatched with a context carrying the
+// caller's identity, e.g. a subscription's configured identity.
+type EventHandlerFunc func(ctx context.Context, event Event) error
+
+// EventHandlerMiddleware wraps an EventHandlerFunc to add cross-cutting
+// behavior such as authorization.
+type EventHandlerMiddleware func(EventHandlerFunc) EventHandlerFunc
+
+// NewAuthorizationEventMiddleware returns an EventHandlerMiddleware that
+// consults store with the identity from ctx and the event type, denying
+// the event with ErrUnauthorized if it is not allowed.
+func NewAuthorizationEventMiddleware(store PolicyStore) EventHandlerMiddleware {
+	return func(next EventHandlerFunc) EventHandlerFunc {
+		return func(ctx context.Context, event Event) error {
+			subject, _ := IdentityFromContext(ctx)
+			target := string(event.EventType())
+			if !store.Allowed(subject, target) {
+				return ErrUnauthorized{
+					Subject: subject,
+					Action:  "handle",
+					Target:  target,
+				}
+			}
+			return next(ctx, event)
+		}
+	}
+}
+
+// EventHandlerAdapter adapts an EventHandlerFunc to the EventHandler
+// interface expected by EventBus.AddHandler, which carries no context for
+// an EventHandlerMiddleware's identity to ride along on. Identity is the
+// fixed subject this subscription authorizes as, e.g. the name of the
+// service or projection registering the handler.
+type EventHandlerAdapter struct {
+	Identity string
+	Handler  EventHandlerFunc
+}
+
+// HandleEvent implements the HandleEvent method of the EventHandler
+// interface by running event through Handler with Identity attached to a
+// background context.
+func (a EventHandlerAdapter) HandleEvent(event Event) error {
+	return a.Handler(WithIdentity(context.Background(), a.Identity), event)
+}
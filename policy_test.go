@@ -0,0 +1,121 @@
+// Copyright (c) 2020 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPolicyStoreAllowByDefault(t *testing.T) {
+	store := NewPolicyStore()
+
+	if !store.Allowed("anyone", "BillingAggregate") {
+		t.Error("a target with no registered policies should be allowed by default")
+	}
+}
+
+func TestPolicyStoreExplicitDeny(t *testing.T) {
+	store := NewPolicyStore()
+	store.Register(Policy{From: []string{"admin"}, To: []string{"BillingAggregate"}})
+
+	if store.Allowed("someone-else", "BillingAggregate") {
+		t.Error("a target with policies but no matching From should be denied")
+	}
+}
+
+func TestPolicyStoreAllowOnMatch(t *testing.T) {
+	store := NewPolicyStore()
+	store.Register(Policy{From: []string{"billing.*"}, To: []string{"BillingAggregate"}})
+
+	if !store.Allowed("billing.admin", "BillingAggregate") {
+		t.Error("a subject matching From should be allowed")
+	}
+	if store.Allowed("other", "BillingAggregate") {
+		t.Error("a subject not matching any policy's From should be denied")
+	}
+}
+
+func TestAuthorizationCommandMiddleware(t *testing.T) {
+	store := NewPolicyStore()
+	store.Register(Policy{From: []string{"admin"}, To: []string{string(TestAggregateType)}})
+
+	var handled bool
+	handler := NewAuthorizationCommandMiddleware(store)(func(ctx context.Context, cmd Command) error {
+		handled = true
+		return nil
+	})
+
+	cmd := TestCommand{TestID: UUID("id1"), Content: "hello"}
+
+	err := handler(WithIdentity(context.Background(), "someone-else"), cmd)
+	if _, ok := err.(ErrUnauthorized); !ok {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+	if handled {
+		t.Error("the wrapped handler should not run when unauthorized")
+	}
+
+	if err := handler(WithIdentity(context.Background(), "admin"), cmd); err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	if !handled {
+		t.Error("the wrapped handler should run when authorized")
+	}
+}
+
+func TestAuthorizationEventMiddleware(t *testing.T) {
+	store := NewPolicyStore()
+	store.Register(Policy{From: []string{"admin"}, To: []string{string(TestEventType)}})
+
+	handler := NewAuthorizationEventMiddleware(store)(func(ctx context.Context, event Event) error {
+		return nil
+	})
+
+	event := NewEvent(TestEventType, &TestEventData{"a"})
+
+	if err := handler(context.Background(), event); err == nil {
+		t.Fatal("an anonymous identity should be denied")
+	}
+	if err := handler(WithIdentity(context.Background(), "admin"), event); err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+}
+
+func TestEventHandlerAdapter(t *testing.T) {
+	store := NewPolicyStore()
+	store.Register(Policy{From: []string{"billing-service"}, To: []string{string(TestEventType)}})
+
+	var handled bool
+	middleware := NewAuthorizationEventMiddleware(store)
+	adapter := EventHandlerAdapter{
+		Identity: "billing-service",
+		Handler: middleware(func(ctx context.Context, event Event) error {
+			handled = true
+			return nil
+		}),
+	}
+
+	event := NewEvent(TestEventType, &TestEventData{"a"})
+	if err := adapter.HandleEvent(event); err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	if !handled {
+		t.Error("the wrapped handler should have run for the adapter's own identity")
+	}
+
+	bus := &MockEventBus{}
+	bus.AddHandler(adapter, TestEventType)
+}
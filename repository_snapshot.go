@@ -0,0 +1,182 @@
+// Copyright (c) 2016 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import "context"
+
+// SnapshotRepository is a Repository that transparently loads and saves
+// snapshots for aggregates registered with RegisterAggregateWithSnapshot,
+// falling back to a full event replay for every other aggregate type or
+// whenever the snapshot store cannot produce a usable snapshot.
+//
+// The tenant for a Load or Save is read from the context with
+// TenantFromContext, e.g. as set by a command bus via WithTenant, and is
+// left empty for single-tenant deployments.
+type SnapshotRepository struct {
+	eventStore    EventStore
+	eventBus      EventBus
+	snapshotStore SnapshotStore
+	policy        SnapshotPolicy
+}
+
+// NewSnapshotRepository creates a SnapshotRepository. The policy is
+// consulted after every Save to decide whether a fresh snapshot should be
+// written; pass nil to never take new snapshots (the store will still be
+// read from on Load).
+func NewSnapshotRepository(eventStore EventStore, eventBus EventBus, snapshotStore SnapshotStore, policy SnapshotPolicy) *SnapshotRepository {
+	return &SnapshotRepository{
+		eventStore:    eventStore,
+		eventBus:      eventBus,
+		snapshotStore: snapshotStore,
+		policy:        policy,
+	}
+}
+
+// Load implements the Load method of the Repository interface.
+//
+// If the aggregate type was registered with RegisterAggregateWithSnapshot a
+// snapshot is loaded first. On success ApplySnapshot is called and only the
+// events with a version greater than the snapshot's are replayed on top of
+// it. If no snapshot is found, or the snapshot store returns an error, Load
+// falls back to replaying the aggregate's full event history.
+func (r *SnapshotRepository) Load(ctx context.Context, aggregateType AggregateType, id UUID) (Aggregate, error) {
+	tenant, _ := TenantFromContext(ctx)
+
+	factory, ok := aggregatesWithSnapshot[aggregateType]
+	if !ok {
+		return r.loadFromEvents(ctx, tenant, aggregateType, id, 0, nil)
+	}
+
+	agg := factory(id)
+	if setter, ok := agg.(TenantSetter); ok {
+		setter.SetTenant(tenant)
+	}
+	snapshotter, ok := agg.(Snapshotter)
+	if !ok {
+		return r.loadFromEvents(ctx, tenant, aggregateType, id, 0, nil)
+	}
+
+	data, version, err := r.snapshotStore.Load(tenant, aggregateType, id)
+	if err != nil {
+		// Fall back to a full replay if the snapshot store is unavailable or
+		// simply has nothing stored for this aggregate yet.
+		return r.loadFromEvents(ctx, tenant, aggregateType, id, 0, nil)
+	}
+
+	if err := snapshotter.ApplySnapshot(data); err != nil {
+		return r.loadFromEvents(ctx, tenant, aggregateType, id, 0, nil)
+	}
+
+	// Seed the aggregate's version from the snapshot before replaying the
+	// tail of events newer than it, so that ApplyEvent's per-event
+	// IncrementVersion calls land on the right version instead of starting
+	// back at zero.
+	if setter, ok := agg.(VersionSetter); ok {
+		setter.SetVersion(version)
+	}
+
+	return r.loadFromEvents(ctx, tenant, aggregateType, id, version, snapshotter)
+}
+
+// loadFromEvents replays events for the aggregate, starting from a blank
+// aggregate unless one reconstructed from a snapshot (with baseVersion > 0)
+// is supplied.
+func (r *SnapshotRepository) loadFromEvents(ctx context.Context, tenant string, aggregateType AggregateType, id UUID, baseVersion int, snapshotted Snapshotter) (Aggregate, error) {
+	events, err := r.loadStream(ctx, tenant, aggregateType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var agg Aggregate
+	if snapshotted != nil {
+		agg = snapshotted.(Aggregate)
+	} else if factory, ok := aggregatesWithSnapshot[aggregateType]; ok {
+		agg = factory(id)
+		if setter, ok := agg.(TenantSetter); ok {
+			setter.SetTenant(tenant)
+		}
+	} else {
+		// aggregateType was never registered with RegisterAggregateWithSnapshot
+		// at all, so fall back to the ordinary aggregate factory registry.
+		var err error
+		agg, err = CreateAggregate(aggregateType, id)
+		if err != nil {
+			return nil, err
+		}
+		if setter, ok := agg.(TenantSetter); ok {
+			setter.SetTenant(tenant)
+		}
+	}
+
+	for _, event := range events {
+		if event.Version() <= baseVersion {
+			continue
+		}
+		agg.ApplyEvent(event)
+	}
+
+	return agg, nil
+}
+
+// loadStream loads the event stream for the aggregate, scoping it to the
+// tenant when the configured EventStore supports it.
+func (r *SnapshotRepository) loadStream(ctx context.Context, tenant string, aggregateType AggregateType, id UUID) ([]Event, error) {
+	if tenantStore, ok := r.eventStore.(TenantEventStore); ok {
+		return tenantStore.LoadStream(ctx, tenant, aggregateType, id)
+	}
+	return r.eventStore.Load(aggregateType, id)
+}
+
+// Save implements the Save method of the Repository interface. After the
+// aggregate's uncommitted events are saved to the event store, the
+// configured SnapshotPolicy is consulted and, if it returns true, a new
+// snapshot is persisted to the snapshot store.
+func (r *SnapshotRepository) Save(ctx context.Context, agg Aggregate) error {
+	tenant, _ := TenantFromContext(ctx)
+
+	if err := r.saveStream(ctx, tenant, agg.UncommittedEvents(), agg.Version()); err != nil {
+		return err
+	}
+
+	for _, event := range agg.UncommittedEvents() {
+		r.eventBus.PublishEvent(event)
+	}
+	agg.ClearUncommittedEvents()
+
+	if r.policy == nil {
+		return nil
+	}
+
+	snapshotter, ok := agg.(Snapshotter)
+	if !ok || !r.policy.ShouldSnapshot(agg) {
+		return nil
+	}
+
+	data, err := snapshotter.TakeSnapshot()
+	if err != nil {
+		return err
+	}
+
+	return r.snapshotStore.Save(tenant, agg.AggregateType(), agg.AggregateID(), agg.Version(), data)
+}
+
+// saveStream saves events to the event store, scoping them to the tenant
+// when the configured EventStore supports it.
+func (r *SnapshotRepository) saveStream(ctx context.Context, tenant string, events []Event, originalVersion int) error {
+	if tenantStore, ok := r.eventStore.(TenantEventStore); ok {
+		return tenantStore.SaveStream(ctx, tenant, events, originalVersion)
+	}
+	return r.eventStore.Save(events, originalVersion)
+}
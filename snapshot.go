@@ -0,0 +1,118 @@
+// Copyright (c) 2016 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import "errors"
+
+// ErrSnapshotNotFound is returned when no snapshot is stored for an aggregate.
+var ErrSnapshotNotFound = errors.New("eventhorizon: snapshot not found")
+
+// ErrSnapshotNotSupported is returned when an aggregate does not implement Snapshotter.
+var ErrSnapshotNotSupported = errors.New("eventhorizon: aggregate does not support snapshots")
+
+// Snapshotter is implemented by aggregates that can serialize and restore
+// their internal state to avoid replaying their full event history on load.
+//
+// An aggregate that wants to be snapshotted should also be registered with
+// RegisterAggregateWithSnapshot instead of (or in addition to) RegisterAggregate.
+type Snapshotter interface {
+	Aggregate
+
+	// TakeSnapshot returns a serialized representation of the current
+	// aggregate state, to be stored alongside its current version.
+	TakeSnapshot() ([]byte, error)
+
+	// ApplySnapshot restores the aggregate state from data previously
+	// returned by TakeSnapshot. It is called before any events are applied.
+	ApplySnapshot(data []byte) error
+}
+
+// SnapshotStore is responsible for loading and saving snapshots of aggregate
+// state, indexed by tenant, aggregate type and ID. tenant may be left empty
+// for single-tenant deployments.
+type SnapshotStore interface {
+	// Load returns the latest stored snapshot for the aggregate along with
+	// the aggregate version it was taken at. It returns ErrSnapshotNotFound
+	// if no snapshot has been saved yet.
+	Load(tenant string, aggregateType AggregateType, id UUID) (data []byte, version int, err error)
+
+	// Save stores a snapshot of the aggregate at the given version,
+	// replacing any previously stored snapshot for the same aggregate.
+	Save(tenant string, aggregateType AggregateType, id UUID, version int, data []byte) error
+}
+
+// VersionSetter is implemented by aggregates (typically via AggregateBase)
+// that can have their version seeded directly, so a SnapshotRepository can
+// initialize an aggregate restored from a snapshot at the snapshot's
+// version before replaying only the events newer than it.
+type VersionSetter interface {
+	SetVersion(version int)
+}
+
+// SnapshotPolicy decides, after an aggregate has been saved, whether a new
+// snapshot should be taken of it.
+type SnapshotPolicy interface {
+	ShouldSnapshot(agg Aggregate) bool
+}
+
+// SnapshotPolicyFunc is an adapter to use ordinary functions as a SnapshotPolicy.
+type SnapshotPolicyFunc func(agg Aggregate) bool
+
+// ShouldSnapshot calls f(agg).
+func (f SnapshotPolicyFunc) ShouldSnapshot(agg Aggregate) bool {
+	return f(agg)
+}
+
+// EveryNEventsSnapshotPolicy returns a SnapshotPolicy that requests a
+// snapshot whenever the aggregate's committed version is an exact multiple
+// of n. A save that carries the version past a multiple of n without
+// landing on it does not request a snapshot. For n <= 0 it never does.
+func EveryNEventsSnapshotPolicy(n int) SnapshotPolicy {
+	return SnapshotPolicyFunc(func(agg Aggregate) bool {
+		if n <= 0 {
+			return false
+		}
+		return agg.Version()%n == 0
+	})
+}
+
+// aggregatesWithSnapshot is a factory map for snapshot-aware aggregates,
+// separate from the plain aggregate factories used by RegisterAggregate so
+// that only aggregates opting into snapshotting pay for the extra interface
+// check on every load.
+var aggregatesWithSnapshot = make(map[AggregateType]func(UUID) Aggregate)
+
+// RegisterAggregateWithSnapshot registers an aggregate factory whose
+// produced aggregates implement Snapshotter, so that a SnapshotRepository
+// can reconstitute them from a snapshot plus the tail of events newer than
+// the snapshot's version, instead of replaying the full history.
+//
+// It panics if the factory does not produce a Snapshotter or if the
+// aggregate type is already registered, mirroring RegisterAggregate.
+func RegisterAggregateWithSnapshot(factory func(UUID) Aggregate) {
+	agg := factory(UUID(""))
+	if _, ok := agg.(Snapshotter); !ok {
+		panic("eventhorizon: aggregate does not implement Snapshotter: " + string(agg.AggregateType()))
+	}
+
+	aggregateType := agg.AggregateType()
+	if aggregateType == AggregateType("") {
+		panic("eventhorizon: attempt to register empty aggregate type")
+	}
+	if _, ok := aggregatesWithSnapshot[aggregateType]; ok {
+		panic("eventhorizon: registering duplicate types for \"" + string(aggregateType) + "\"")
+	}
+	aggregatesWithSnapshot[aggregateType] = factory
+}
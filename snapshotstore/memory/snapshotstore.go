@@ -0,0 +1,72 @@
+// Copyright (c) 2016 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memory provides an in-memory eh.SnapshotStore, useful for tests
+// and single-process deployments.
+package memory
+
+import (
+	"sync"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+type snapshot struct {
+	version int
+	data    []byte
+}
+
+// snapshotKey scopes a snapshot to a tenant (empty for single-tenant
+// deployments), so that aggregates with the same type and ID in different
+// tenants do not collide.
+type snapshotKey struct {
+	tenant        string
+	aggregateType eh.AggregateType
+	id            eh.UUID
+}
+
+// SnapshotStore implements eh.SnapshotStore by keeping the latest snapshot
+// for each aggregate in memory. It is safe for concurrent use.
+type SnapshotStore struct {
+	snapshotsMu sync.RWMutex
+	snapshots   map[snapshotKey]snapshot
+}
+
+// NewSnapshotStore creates a new SnapshotStore.
+func NewSnapshotStore() *SnapshotStore {
+	return &SnapshotStore{
+		snapshots: make(map[snapshotKey]snapshot),
+	}
+}
+
+// Load implements the Load method of the eh.SnapshotStore interface.
+func (s *SnapshotStore) Load(tenant string, aggregateType eh.AggregateType, id eh.UUID) ([]byte, int, error) {
+	s.snapshotsMu.RLock()
+	defer s.snapshotsMu.RUnlock()
+
+	snap, ok := s.snapshots[snapshotKey{tenant, aggregateType, id}]
+	if !ok {
+		return nil, 0, eh.ErrSnapshotNotFound
+	}
+	return snap.data, snap.version, nil
+}
+
+// Save implements the Save method of the eh.SnapshotStore interface.
+func (s *SnapshotStore) Save(tenant string, aggregateType eh.AggregateType, id eh.UUID, version int, data []byte) error {
+	s.snapshotsMu.Lock()
+	defer s.snapshotsMu.Unlock()
+
+	s.snapshots[snapshotKey{tenant, aggregateType, id}] = snapshot{version: version, data: data}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2016 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mongodb provides a MongoDB eh.SnapshotStore.
+package mongodb
+
+import (
+	eh "github.com/looplab/eventhorizon"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// defaultTenant is the value stored for the tenant field of documents
+// written before multi-tenancy was introduced, or written for a "" tenant.
+const defaultTenant = eh.DefaultTenant
+
+// SnapshotStore implements eh.SnapshotStore using MongoDB, storing one
+// document per tenant's aggregate holding its latest snapshot.
+type SnapshotStore struct {
+	session  *mgo.Session
+	dbPrefix string
+}
+
+// snapshotDocument is the DB representation of a stored snapshot.
+type snapshotDocument struct {
+	Tenant        string           `bson:"tenant"`
+	AggregateType eh.AggregateType `bson:"aggregate_type"`
+	AggregateID   eh.UUID          `bson:"aggregate_id"`
+	Version       int              `bson:"version"`
+	Data          []byte           `bson:"data"`
+}
+
+// NewSnapshotStore creates a new SnapshotStore with a MongoDB URI and
+// database prefix, following the eventstore/mongodb convention of prefixing
+// the "snapshots" collection's database name.
+func NewSnapshotStore(url, dbPrefix string) (*SnapshotStore, error) {
+	session, err := mgo.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	session.SetMode(mgo.Strong, true)
+	session.SetSafe(&mgo.Safe{W: 1})
+
+	return NewSnapshotStoreWithSession(session, dbPrefix), nil
+}
+
+// NewSnapshotStoreWithSession creates a new SnapshotStore with a pre-existing
+// MongoDB session.
+func NewSnapshotStoreWithSession(session *mgo.Session, dbPrefix string) *SnapshotStore {
+	return &SnapshotStore{
+		session:  session,
+		dbPrefix: dbPrefix,
+	}
+}
+
+func (s *SnapshotStore) db() *mgo.Database {
+	return s.session.DB(s.dbPrefix + "_eventhorizon")
+}
+
+func (s *SnapshotStore) collection() *mgo.Collection {
+	sess := s.session.Copy()
+	return s.db().C("snapshots").With(sess)
+}
+
+// Load implements the Load method of the eh.SnapshotStore interface.
+func (s *SnapshotStore) Load(tenant string, aggregateType eh.AggregateType, id eh.UUID) ([]byte, int, error) {
+	c := s.collection()
+	defer c.Database.Session.Close()
+
+	var doc snapshotDocument
+	err := c.Find(bson.M{
+		"tenant":         tenantKey(tenant),
+		"aggregate_type": aggregateType,
+		"aggregate_id":   id,
+	}).One(&doc)
+	if err == mgo.ErrNotFound {
+		return nil, 0, eh.ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return doc.Data, doc.Version, nil
+}
+
+// Save implements the Save method of the eh.SnapshotStore interface.
+func (s *SnapshotStore) Save(tenant string, aggregateType eh.AggregateType, id eh.UUID, version int, data []byte) error {
+	c := s.collection()
+	defer c.Database.Session.Close()
+
+	key := tenantKey(tenant)
+	_, err := c.Upsert(bson.M{
+		"tenant":         key,
+		"aggregate_type": aggregateType,
+		"aggregate_id":   id,
+	}, snapshotDocument{
+		Tenant:        key,
+		AggregateType: aggregateType,
+		AggregateID:   id,
+		Version:       version,
+		Data:          data,
+	})
+	return err
+}
+
+// tenantKey normalizes the empty (single-tenant) tenant to defaultTenant, so
+// that every document in the collection carries a non-empty tenant field
+// and can be indexed and migrated uniformly.
+func tenantKey(tenant string) string {
+	if tenant == "" {
+		return defaultTenant
+	}
+	return tenant
+}
+
+// EnsureIndexes creates the compound index needed to look up a snapshot by
+// tenant and aggregate, and should be called once on startup.
+func (s *SnapshotStore) EnsureIndexes() error {
+	c := s.collection()
+	defer c.Database.Session.Close()
+
+	return c.EnsureIndex(mgo.Index{
+		Key:    []string{"tenant", "aggregate_type", "aggregate_id"},
+		Unique: true,
+	})
+}
+
+// MigrateToTenant backfills the tenant field on documents written before
+// multi-tenancy was introduced (or by a pre-migration version of this
+// store), assigning them to defaultTenant so they keep resolving for
+// single-tenant callers after the unique index is rebuilt.
+func (s *SnapshotStore) MigrateToTenant() error {
+	c := s.collection()
+	defer c.Database.Session.Close()
+
+	_, err := c.UpdateAll(
+		bson.M{"tenant": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"tenant": defaultTenant}},
+	)
+	return err
+}
+
+// Close closes the underlying MongoDB session.
+func (s *SnapshotStore) Close() {
+	s.session.Close()
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2019 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import "context"
+
+// tenantContextKey is an unexported type to avoid collisions with context
+// keys from other packages, following the standard context.Context pattern.
+type tenantContextKey int
+
+const tenantKey tenantContextKey = 0
+
+// DefaultTenant is the value a tenant-scoped store backend should normalize
+// an empty (single-tenant) tenant to, so that every stored document carries
+// a non-empty tenant and a compound index over it stays unique. Backends
+// that shard by tenant, such as the snapshotstore/mongodb and
+// eventstore/mongodb packages, should use this instead of inventing their
+// own default so that the same aggregate resolves to the same tenant key
+// across every store in a deployment.
+const DefaultTenant = "default"
+
+// WithTenant returns a copy of ctx carrying the given tenant, so that it can
+// be picked up on the other end of a command or query by TenantFromContext,
+// e.g. by a command bus before loading the target aggregate.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// TenantFromContext returns the tenant previously attached with WithTenant,
+// and false if ctx carries none.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey).(string)
+	return tenant, ok
+}
+
+// TenantEventStore is implemented by EventStore backends that can scope a
+// stream to a tenant, so that a single deployment can isolate aggregates
+// for many tenants without their IDs colliding. Stores that do not
+// implement it are treated as single-tenant.
+type TenantEventStore interface {
+	// LoadStream loads the events for the given tenant's aggregate.
+	LoadStream(ctx context.Context, tenant string, aggregateType AggregateType, id UUID) ([]Event, error)
+
+	// SaveStream saves events for the given tenant's aggregate, enforcing
+	// originalVersion the same way EventStore.Save does.
+	SaveStream(ctx context.Context, tenant string, events []Event, originalVersion int) error
+}
+
+// TenantSetter is implemented by aggregates (typically via AggregateBase)
+// that can be assigned a tenant after construction, so a repository can set
+// it on an aggregate obtained from a factory before replaying its events.
+type TenantSetter interface {
+	SetTenant(tenant string)
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2019 - Max Ekman <max@looplab.se>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhorizon
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDefaultTenantIsNonEmpty(t *testing.T) {
+	if DefaultTenant == "" {
+		t.Error("DefaultTenant must be non-empty, or tenant-scoped stores could collide with a real tenant named \"\"")
+	}
+}
+
+func TestWithTenantAndTenantFromContext(t *testing.T) {
+	if _, ok := TenantFromContext(context.Background()); ok {
+		t.Error("a plain context should carry no tenant")
+	}
+
+	ctx := WithTenant(context.Background(), "acme")
+	tenant, ok := TenantFromContext(ctx)
+	if !ok || tenant != "acme" {
+		t.Error("the tenant should round-trip through the context:", tenant, ok)
+	}
+}
+
+func TestAggregateBaseStampsTenantOnEvents(t *testing.T) {
+	agg := NewAggregateBase(TestAggregateType, UUID("id1"), "acme")
+
+	event := agg.NewEvent(TestEventType, &TestEventData{"a"})
+	tenanted, ok := event.(interface{ Tenant() string })
+	if !ok || tenanted.Tenant() != "acme" {
+		t.Error("the event should be stamped with the aggregate's tenant")
+	}
+}
+
+type tenantScopedEventStore struct {
+	eventsByTenant map[string][]Event
+}
+
+func (s *tenantScopedEventStore) Save(events []Event, originalVersion int) error {
+	panic("Save should not be called when LoadStream/SaveStream are available")
+}
+
+func (s *tenantScopedEventStore) Load(aggregateType AggregateType, id UUID) ([]Event, error) {
+	panic("Load should not be called when LoadStream/SaveStream are available")
+}
+
+func (s *tenantScopedEventStore) LoadStream(ctx context.Context, tenant string, aggregateType AggregateType, id UUID) ([]Event, error) {
+	return s.eventsByTenant[tenant], nil
+}
+
+func (s *tenantScopedEventStore) SaveStream(ctx context.Context, tenant string, events []Event, originalVersion int) error {
+	s.eventsByTenant[tenant] = append(s.eventsByTenant[tenant], events...)
+	return nil
+}
+
+func TestSnapshotRepositoryScopesEventStoreByTenant(t *testing.T) {
+	store := &tenantScopedEventStore{eventsByTenant: make(map[string][]Event)}
+	repo := NewSnapshotRepository(store, &MockEventBus{}, &mockSnapshotStore{}, nil)
+
+	agg := NewTestSnapshotAggregate(UUID("id1"))
+	agg.SetTenant("acme")
+	agg.StoreEvent(agg.NewEvent(TestEventType, &TestEventData{"a"}))
+
+	if err := repo.Save(WithTenant(context.Background(), "acme"), agg); err != nil {
+		t.Fatal("there should be no error:", err)
+	}
+	if len(store.eventsByTenant["acme"]) != 1 {
+		t.Error("the event should have been saved under the acme tenant:", store.eventsByTenant)
+	}
+	if len(store.eventsByTenant["other"]) != 0 {
+		t.Error("no events should leak into other tenants")
+	}
+}